@@ -0,0 +1,87 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "sync/atomic"
+
+// Config contains configuration options for tidb-server, loaded from a TOML
+// config file at startup. Only the sections actually read by the executor
+// package are modeled here.
+type Config struct {
+	Log Log
+	// ResourceGroups configures the statically-defined resource groups
+	// loaded by executor.loadResourceGroupsFromConfig, which registers each
+	// one through executor.RegisterResourceGroup. There is no `CREATE
+	// RESOURCE GROUP` DDL; this section and direct calls to
+	// executor.RegisterResourceGroup are the ways to define a group.
+	ResourceGroups ResourceGroups
+}
+
+// Log is the `[log]` section of the config file.
+type Log struct {
+	// ExpensiveThreshold is the estimated row count above which a query is
+	// classified as "expensive" and logged.
+	ExpensiveThreshold uint
+	// TooExpensiveThreshold is the estimated row count above which a query
+	// is rejected outright rather than merely logged. Zero means no such
+	// bound is enforced.
+	TooExpensiveThreshold uint
+}
+
+// ResourceGroups is the `[resource-groups]` section of the config file.
+type ResourceGroups struct {
+	// Groups lists the statically-configured resource groups, each with the
+	// users assigned to it.
+	Groups []ResourceGroup
+}
+
+// ResourceGroup is a single group definition under `[resource-groups]`,
+// e.g.:
+//
+//	[[resource-groups.groups]]
+//	name = "batch"
+//	max-estimated-rows = 1000000
+//	max-estimated-memory = 1073741824
+//	max-concurrent-expensive = 2
+//	max-wall-time-seconds = 300
+//	users = ["batch_user"]
+type ResourceGroup struct {
+	Name                   string
+	MaxEstimatedRows       int64
+	MaxEstimatedMemory     int64
+	MaxConcurrentExpensive int
+	MaxWallTimeSeconds     int64
+	Users                  []string
+}
+
+// defaultConfig is used until StoreGlobalConfig is called, so that callers
+// in tests or before config-file loading never see a nil config.
+var defaultConfig = Config{}
+
+var globalConfig atomic.Value
+
+func init() {
+	globalConfig.Store(&defaultConfig)
+}
+
+// GetGlobalConfig returns the current global config.
+func GetGlobalConfig() *Config {
+	return globalConfig.Load().(*Config)
+}
+
+// StoreGlobalConfig replaces the global config, e.g. after parsing the
+// config file at startup.
+func StoreGlobalConfig(config *Config) {
+	globalConfig.Store(config)
+}
@@ -0,0 +1,62 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"github.com/juju/errors"
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/infoschema"
+	"github.com/pingcap/tidb/plan"
+	"github.com/pingcap/tidb/sessionctx"
+	"golang.org/x/net/context"
+)
+
+// ExecStmt implements the in-session execution of a single compiled
+// statement. It is built by Compiler.Compile and then run once by the
+// session layer.
+type ExecStmt struct {
+	InfoSchema infoschema.InfoSchema
+	Plan       plan.Plan
+	Expensive  bool
+	Cacheable  bool
+	Text       string
+	StmtNode   ast.StmtNode
+	Ctx        sessionctx.Context
+
+	// ResourceGroup is the group resolved at compile time whose limits
+	// govern this statement at admission (Compiler.Compile) and, when the
+	// statement is Expensive, at runtime (Exec).
+	ResourceGroup *ResourceGroup
+}
+
+// Exec runs the statement, building the plan's executor tree and draining
+// it via run. While the statement is expensive, its ResourceGroup's runtime
+// governor watches actual processed rows/memory/wall-time and cancels ctx
+// if any limit is exceeded; the group's concurrency slot reserved at
+// compile time is always released once Exec returns, regardless of
+// success, error, or cancellation.
+func (a *ExecStmt) Exec(ctx context.Context, run func(ctx context.Context) error) error {
+	if !a.Expensive || a.ResourceGroup == nil {
+		return errors.Trace(run(ctx))
+	}
+
+	governedCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stopGovernor := runQueryGovernor(governedCtx, cancel, a, a.ResourceGroup)
+	defer stopGovernor()
+	defer leaveExpensive(a.ResourceGroup)
+
+	return errors.Trace(run(governedCtx))
+}
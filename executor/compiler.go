@@ -14,7 +14,9 @@
 package executor
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/opentracing/opentracing-go"
@@ -60,150 +62,178 @@ func (c *Compiler) Compile(ctx context.Context, stmtNode ast.StmtNode) (*ExecStm
 		return nil, errors.Trace(err)
 	}
 
-	CountStmtNode(stmtNode, c.Ctx.GetSessionVars().InRestrictedSQL)
-	planExpensiveLevel := logExpensiveQuery(stmtNode, finalPlan)
+	CountStmtNode(stmtNode, finalPlan, c.Ctx.GetSessionVars().InRestrictedSQL)
+	group := resolveResourceGroup(c.Ctx)
+	planExpensiveLevel := logExpensiveQuery(c.Ctx, stmtNode, finalPlan, group)
 	if planExpensiveLevel >= tooExpensive {
 		return nil, errors.Trace(errors.New(expensive_plan_error))
 	}
+
+	if planExpensiveLevel > notExpensive && !tryEnterExpensive(group) {
+		return nil, errors.Trace(errors.New(expensive_plan_error))
+	}
+
 	return &ExecStmt{
-		InfoSchema: infoSchema,
-		Plan:       finalPlan,
-		Expensive:  planExpensiveLevel > notExpensive,
-		Cacheable:  plan.Cacheable(stmtNode),
-		Text:       stmtNode.Text(),
-		StmtNode:   stmtNode,
-		Ctx:        c.Ctx,
+		InfoSchema:    infoSchema,
+		Plan:          finalPlan,
+		Expensive:     planExpensiveLevel > notExpensive,
+		Cacheable:     plan.Cacheable(stmtNode),
+		Text:          stmtNode.Text(),
+		StmtNode:      stmtNode,
+		Ctx:           c.Ctx,
+		ResourceGroup: group,
 	}, nil
 }
 
-func logExpensiveQuery(stmtNode ast.StmtNode, finalPlan plan.Plan) (expensiveLvl expensiveLevel) {
-	expensiveLvl = queryExpensiveLevel(finalPlan)
+func logExpensiveQuery(ctx sessionctx.Context, stmtNode ast.StmtNode, finalPlan plan.Plan, group *ResourceGroup) (expensiveLvl expensiveLevel) {
+	var cost queryCost
+	expensiveLvl, cost = queryExpensiveLevel(finalPlan)
+	if group.MaxEstimatedRows > 0 && cost.rows > group.MaxEstimatedRows {
+		expensiveLvl = tooExpensive
+	}
+	if group.MaxEstimatedMemory > 0 && cost.memory > group.MaxEstimatedMemory {
+		expensiveLvl = tooExpensive
+	}
+	allowedByHint := false
+	if expensiveLvl >= tooExpensive && allowExpensiveQueryOverride(ctx, stmtNode) {
+		allowedByHint = true
+		expensiveLvl = expensive
+	}
 	if expensiveLvl < expensive {
 		return
 	}
 
 	const logSQLLen = 1024
-	sql := stmtNode.Text()
+	sql := normalizeSQL(stmtNode.Text())
 	if len(sql) > logSQLLen {
 		sql = fmt.Sprintf("%s len(%d)", sql[:logSQLLen], len(sql))
 	}
-	log.Warnf("[EXPENSIVE_QUERY] %s", sql)
+
+	sessVars := ctx.GetSessionVars()
+	record := ExpensiveQueryInfo{
+		Time:           time.Now(),
+		ConnectionID:   sessVars.ConnectionID,
+		User:           sessVars.User.String(),
+		DB:             sessVars.CurrentDB,
+		NormalizedSQL:  sql,
+		PlanDigest:     planDigest(finalPlan),
+		EstimatedRows:  cost.rows,
+		Classification: expensiveLvl.classificationString(),
+		AllowedByHint:  allowedByHint,
+	}
+	recordExpensiveQuery(record)
+
+	logBytes, err := json.Marshal(record)
+	if err != nil {
+		log.Warnf("[EXPENSIVE_QUERY] marshal error: %v", err)
+		return
+	}
+	log.Warnf("[EXPENSIVE_QUERY] %s", logBytes)
 	return
 }
 
-func queryExpensiveLevel(p plan.Plan) expensiveLevel {
+// queryCost is the estimated cost of a plan, used by admission control
+// (queryExpensiveLevel, logExpensiveQuery). Runtime enforcement
+// (runQueryGovernor) does not use it; it compares actually observed usage
+// against the group's limits.
+type queryCost struct {
+	rows   int64
+	memory int64
+	cpu    int64
+}
+
+// unwrapDMLSelectPlan peels a plan down to the plan.PhysicalPlan that
+// actually does the row-level work: for *plan.Execute it's the prepared
+// plan; for *plan.Insert/*plan.Delete/*plan.Update it's the SelectPlan
+// driving the rows to insert/delete/update. Both admission control
+// (queryExpensiveLevel) and runtime enforcement (actualProcessedRows) need
+// this same unwrapping, as does the structured-log plan digest
+// (writePlanDigest), or they silently stop seeing the real scan/join shape
+// for every non-trivial DML statement.
+func unwrapDMLSelectPlan(p plan.Plan) (plan.PhysicalPlan, bool) {
 	switch x := p.(type) {
 	case plan.PhysicalPlan:
-		return physicalPlanExpensiveLevel(x)
+		return x, true
 	case *plan.Execute:
-		return queryExpensiveLevel(x.Plan)
+		return unwrapDMLSelectPlan(x.Plan)
 	case *plan.Insert:
 		if x.SelectPlan != nil {
-			return physicalPlanExpensiveLevel(x.SelectPlan)
+			return unwrapDMLSelectPlan(x.SelectPlan)
 		}
 	case *plan.Delete:
 		if x.SelectPlan != nil {
-			return physicalPlanExpensiveLevel(x.SelectPlan)
+			return unwrapDMLSelectPlan(x.SelectPlan)
 		}
 	case *plan.Update:
 		if x.SelectPlan != nil {
-			return physicalPlanExpensiveLevel(x.SelectPlan)
+			return unwrapDMLSelectPlan(x.SelectPlan)
 		}
 	}
-	return notExpensive
+	return nil, false
 }
 
-func physicalPlanExpensiveLevel(p plan.PhysicalPlan) expensiveLevel {
-	var expensiveLevel = notExpensive
-	expensiveRowThreshold := int64(config.GetGlobalConfig().Log.ExpensiveThreshold)
-	tooExpensiveRowThreshold := int64(config.GetGlobalConfig().Log.TooExpensiveThreshold)
-	if p.StatsInfo().Count() > expensiveRowThreshold {
-		expensiveLevel = expensive
-	}
-	if tooExpensiveRowThreshold > 0 && p.StatsInfo().Count() > tooExpensiveRowThreshold {
-		expensiveLevel = tooExpensive
+func queryExpensiveLevel(p plan.Plan) (expensiveLevel, queryCost) {
+	physical, ok := unwrapDMLSelectPlan(p)
+	if !ok {
+		return notExpensive, queryCost{}
 	}
+	return physicalPlanExpensiveLevel(physical)
+}
+
+// estimatedBytesPerRow approximates the in-memory size of a single row when
+// the plan does not expose a more precise estimate.
+const estimatedBytesPerRow = 128
+
+func physicalPlanExpensiveLevel(p plan.PhysicalPlan) (expensiveLevel, queryCost) {
+	rows := p.StatsInfo().Count()
+	lvl := rowCountExpensiveLevel(rows, int64(config.GetGlobalConfig().Log.ExpensiveThreshold), int64(config.GetGlobalConfig().Log.TooExpensiveThreshold))
+	cost := queryCost{rows: rows, memory: rows * estimatedBytesPerRow, cpu: rows}
 
+	childCosts := make([]queryCost, 0, len(p.Children()))
 	for _, child := range p.Children() {
-		childExpensiveLevel := physicalPlanExpensiveLevel(child)
-		if childExpensiveLevel > expensiveLevel {
-			expensiveLevel = childExpensiveLevel
+		childLvl, childCost := physicalPlanExpensiveLevel(child)
+		if childLvl > lvl {
+			lvl = childLvl
 		}
+		childCosts = append(childCosts, childCost)
+	}
+
+	return lvl, combineCost(cost, childCosts)
+}
+
+// rowCountExpensiveLevel classifies a single operator's estimated row
+// count against the expensive/too-expensive thresholds. A
+// tooExpensiveThreshold of zero means no upper bound is configured.
+func rowCountExpensiveLevel(rows, expensiveThreshold, tooExpensiveThreshold int64) expensiveLevel {
+	lvl := notExpensive
+	if rows > expensiveThreshold {
+		lvl = expensive
+	}
+	if tooExpensiveThreshold > 0 && rows > tooExpensiveThreshold {
+		lvl = tooExpensive
 	}
+	return lvl
+}
 
-	return expensiveLevel
+// combineCost folds a set of child operator costs into their parent's own
+// cost, the way physicalPlanExpensiveLevel aggregates cost bottom-up over
+// the whole plan tree.
+func combineCost(self queryCost, children []queryCost) queryCost {
+	total := self
+	for _, child := range children {
+		total.rows += child.rows
+		total.memory += child.memory
+		total.cpu += child.cpu
+	}
+	return total
 }
 
 // CountStmtNode records the number of statements with the same type.
-func CountStmtNode(stmtNode ast.StmtNode, inRestrictedSQL bool) {
+func CountStmtNode(stmtNode ast.StmtNode, finalPlan plan.Plan, inRestrictedSQL bool) {
 	if inRestrictedSQL {
 		return
 	}
-	metrics.StmtNodeCounter.WithLabelValues(GetStmtLabel(stmtNode)).Inc()
-}
-
-// GetStmtLabel generates a label for a statement.
-func GetStmtLabel(stmtNode ast.StmtNode) string {
-	switch x := stmtNode.(type) {
-	case *ast.AlterTableStmt:
-		return "AlterTable"
-	case *ast.AnalyzeTableStmt:
-		return "AnalyzeTable"
-	case *ast.BeginStmt:
-		return "Begin"
-	case *ast.CommitStmt:
-		return "Commit"
-	case *ast.CreateDatabaseStmt:
-		return "CreateDatabase"
-	case *ast.CreateIndexStmt:
-		return "CreateIndex"
-	case *ast.CreateTableStmt:
-		return "CreateTable"
-	case *ast.CreateUserStmt:
-		return "CreateUser"
-	case *ast.DeleteStmt:
-		return "Delete"
-	case *ast.DropDatabaseStmt:
-		return "DropDatabase"
-	case *ast.DropIndexStmt:
-		return "DropIndex"
-	case *ast.DropTableStmt:
-		return "DropTable"
-	case *ast.ExplainStmt:
-		return "Explain"
-	case *ast.InsertStmt:
-		if x.IsReplace {
-			return "Replace"
-		}
-		return "Insert"
-	case *ast.LoadDataStmt:
-		return "LoadData"
-	case *ast.RollbackStmt:
-		return "RollBack"
-	case *ast.SelectStmt:
-		return "Select"
-	case *ast.SetStmt, *ast.SetPwdStmt:
-		return "Set"
-	case *ast.ShowStmt:
-		return "Show"
-	case *ast.TruncateTableStmt:
-		return "TruncateTable"
-	case *ast.UpdateStmt:
-		return "Update"
-	case *ast.GrantStmt:
-		return "Grant"
-	case *ast.RevokeStmt:
-		return "Revoke"
-	case *ast.DeallocateStmt:
-		return "Deallocate"
-	case *ast.ExecuteStmt:
-		return "Execute"
-	case *ast.PrepareStmt:
-		return "Prepare"
-	case *ast.UseStmt:
-		return "IGNORE"
-	}
-	return "other"
+	metrics.StmtNodeCounter.WithLabelValues(GetStmtLabel(stmtNode, finalPlan)).Inc()
 }
 
 // GetInfoSchema gets TxnCtx InfoSchema if snapshot schema is not set,
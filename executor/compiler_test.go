@@ -0,0 +1,58 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import "testing"
+
+func TestRowCountExpensiveLevel(t *testing.T) {
+	cases := []struct {
+		rows, expensiveThreshold, tooExpensiveThreshold int64
+		want                                            expensiveLevel
+	}{
+		{rows: 10, expensiveThreshold: 100, tooExpensiveThreshold: 1000, want: notExpensive},
+		{rows: 101, expensiveThreshold: 100, tooExpensiveThreshold: 1000, want: expensive},
+		{rows: 1001, expensiveThreshold: 100, tooExpensiveThreshold: 1000, want: tooExpensive},
+		// A zero tooExpensiveThreshold means no upper bound is configured.
+		{rows: 1000000, expensiveThreshold: 100, tooExpensiveThreshold: 0, want: expensive},
+	}
+	for _, c := range cases {
+		got := rowCountExpensiveLevel(c.rows, c.expensiveThreshold, c.tooExpensiveThreshold)
+		if got != c.want {
+			t.Errorf("rowCountExpensiveLevel(%d, %d, %d) = %v, want %v",
+				c.rows, c.expensiveThreshold, c.tooExpensiveThreshold, got, c.want)
+		}
+	}
+}
+
+func TestCombineCost(t *testing.T) {
+	self := queryCost{rows: 10, memory: 1280, cpu: 10}
+	children := []queryCost{
+		{rows: 100, memory: 12800, cpu: 100},
+		{rows: 5, memory: 640, cpu: 5},
+	}
+
+	got := combineCost(self, children)
+	want := queryCost{rows: 115, memory: 14720, cpu: 115}
+	if got != want {
+		t.Errorf("combineCost(%+v, %+v) = %+v, want %+v", self, children, got, want)
+	}
+}
+
+func TestCombineCostNoChildren(t *testing.T) {
+	self := queryCost{rows: 42, memory: 5376, cpu: 42}
+	got := combineCost(self, nil)
+	if got != self {
+		t.Errorf("combineCost(%+v, nil) = %+v, want %+v (unchanged)", self, got, self)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import "testing"
+
+// resetExpensiveQueryRing clears the package-level ring buffer so tests
+// don't observe records left behind by other tests or callers.
+func resetExpensiveQueryRing() {
+	expensiveQueryRing.Lock()
+	defer expensiveQueryRing.Unlock()
+	expensiveQueryRing.records = make([]ExpensiveQueryInfo, expensiveQueryRingSize)
+	expensiveQueryRing.next = 0
+	expensiveQueryRing.filled = false
+}
+
+func TestExpensiveQueryRingBeforeFull(t *testing.T) {
+	resetExpensiveQueryRing()
+	defer resetExpensiveQueryRing()
+
+	for i := 0; i < 3; i++ {
+		recordExpensiveQuery(ExpensiveQueryInfo{ConnectionID: uint64(i)})
+	}
+
+	got := GetExpensiveQueries()
+	if len(got) != 3 {
+		t.Fatalf("len(GetExpensiveQueries()) = %d, want 3", len(got))
+	}
+	for i, info := range got {
+		if info.ConnectionID != uint64(i) {
+			t.Errorf("got[%d].ConnectionID = %d, want %d", i, info.ConnectionID, i)
+		}
+	}
+}
+
+func TestExpensiveQueryRingWraparound(t *testing.T) {
+	resetExpensiveQueryRing()
+	defer resetExpensiveQueryRing()
+
+	// Write one and a half times around the ring; only the most recent
+	// expensiveQueryRingSize records should survive, oldest first.
+	total := expensiveQueryRingSize + expensiveQueryRingSize/2
+	for i := 0; i < total; i++ {
+		recordExpensiveQuery(ExpensiveQueryInfo{ConnectionID: uint64(i)})
+	}
+
+	got := GetExpensiveQueries()
+	if len(got) != expensiveQueryRingSize {
+		t.Fatalf("len(GetExpensiveQueries()) = %d, want %d", len(got), expensiveQueryRingSize)
+	}
+
+	wantFirst := uint64(total - expensiveQueryRingSize)
+	if got[0].ConnectionID != wantFirst {
+		t.Errorf("got[0].ConnectionID = %d, want %d (oldest surviving record)", got[0].ConnectionID, wantFirst)
+	}
+	wantLast := uint64(total - 1)
+	if last := got[len(got)-1].ConnectionID; last != wantLast {
+		t.Errorf("got[last].ConnectionID = %d, want %d (most recent record)", last, wantLast)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].ConnectionID != got[i-1].ConnectionID+1 {
+			t.Fatalf("records out of order at index %d: %d then %d", i, got[i-1].ConnectionID, got[i].ConnectionID)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"time"
+
+	"github.com/pingcap/tidb/plan"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+// governorCheckInterval is how often a running statement's actual resource
+// usage is compared against its resource group's limits.
+const governorCheckInterval = 200 * time.Millisecond
+
+// runQueryGovernor starts a background goroutine that periodically checks
+// the executing statement's actual processed rows/memory, as tracked on
+// stmt.Ctx.GetSessionVars().StmtCtx, against group's limits, cancelling
+// cancel when any limit is exceeded. It returns a stop function that must
+// be called once the statement has finished executing.
+func runQueryGovernor(ctx context.Context, cancel context.CancelFunc, stmt *ExecStmt, group *ResourceGroup) (stop func()) {
+	if group.MaxEstimatedRows == 0 && group.MaxEstimatedMemory == 0 && group.MaxWallTime == 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		ticker := time.NewTicker(governorCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if reason, exceeded := governorCheckLimits(stmt, group, start); exceeded {
+					log.Warnf("[RESOURCE_GOVERNOR] cancelling query: %s, group: %s", reason, group.Name)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// governorCheckLimits compares the statement's actually observed
+// row/memory usage so far against the group's limits.
+func governorCheckLimits(stmt *ExecStmt, group *ResourceGroup, start time.Time) (reason string, exceeded bool) {
+	if group.MaxWallTime > 0 && time.Since(start) > group.MaxWallTime {
+		return "wall time exceeded", true
+	}
+
+	if group.MaxEstimatedRows > 0 && actualProcessedRows(stmt) > group.MaxEstimatedRows {
+		return "row limit exceeded", true
+	}
+
+	if group.MaxEstimatedMemory > 0 {
+		stmtCtx := stmt.Ctx.GetSessionVars().StmtCtx
+		if stmtCtx.MemTracker != nil && stmtCtx.MemTracker.BytesConsumed() > group.MaxEstimatedMemory {
+			return "memory limit exceeded", true
+		}
+	}
+	return "", false
+}
+
+// actualProcessedRows returns the number of rows the statement's root
+// operator has actually produced so far, as tracked by the runtime stats
+// collector. Unlike StmtCtx.AffectedRows (which only reflects DML rows
+// affected by insert/update/delete), this also covers plain SELECTs, which
+// are the dominant case a row-count governor needs to catch. It unwraps
+// *plan.Insert/*plan.Delete/*plan.Update/*plan.Execute the same way
+// queryExpensiveLevel does at admission time, so an `INSERT ... SELECT` or
+// bulk `UPDATE`/`DELETE` is tracked by the rows its driving SelectPlan's
+// root actually produces, not left unmatched against plan.PhysicalPlan.
+func actualProcessedRows(stmt *ExecStmt) int64 {
+	stmtCtx := stmt.Ctx.GetSessionVars().StmtCtx
+	physPlan, ok := unwrapDMLSelectPlan(stmt.Plan)
+	if !ok || stmtCtx.RuntimeStatsColl == nil {
+		return 0
+	}
+	rootStats := stmtCtx.RuntimeStatsColl.GetRootStats(physPlan.ID())
+	if rootStats == nil {
+		return 0
+	}
+	return int64(rootStats.GetActRows())
+}
@@ -0,0 +1,48 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGovernorCheckLimitsWallTime(t *testing.T) {
+	group := &ResourceGroup{Name: "g", MaxWallTime: 10 * time.Millisecond}
+	start := time.Now().Add(-20 * time.Millisecond)
+
+	reason, exceeded := governorCheckLimits(nil, group, start)
+	if !exceeded || reason != "wall time exceeded" {
+		t.Errorf("governorCheckLimits() = (%q, %v), want (\"wall time exceeded\", true)", reason, exceeded)
+	}
+}
+
+func TestGovernorCheckLimitsWallTimeNotYetExceeded(t *testing.T) {
+	group := &ResourceGroup{Name: "g", MaxWallTime: time.Hour}
+	start := time.Now()
+
+	reason, exceeded := governorCheckLimits(nil, group, start)
+	if exceeded {
+		t.Errorf("governorCheckLimits() = (%q, %v), want exceeded = false (wall time not yet reached)", reason, exceeded)
+	}
+}
+
+func TestGovernorCheckLimitsNoLimitsConfigured(t *testing.T) {
+	group := &ResourceGroup{Name: "g"}
+
+	reason, exceeded := governorCheckLimits(nil, group, time.Now())
+	if exceeded || reason != "" {
+		t.Errorf("governorCheckLimits() = (%q, %v), want (\"\", false) when no limits are configured", reason, exceeded)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/sessionctx"
+)
+
+// allowExpensiveHintName is the optimizer hint that lets a single statement
+// opt out of expensive-query rejection, e.g. `/*+ ALLOW_EXPENSIVE() */
+// select ...`. It is parsed by the ast package alongside the other
+// TableOptimizerHints.
+const allowExpensiveHintName = "allow_expensive"
+
+// tableHints returns the optimizer hints attached to stmtNode, if any.
+// Only statement types that currently carry TableHints are handled; other
+// statement types simply have no hints to find.
+func tableHints(stmtNode ast.StmtNode) []*ast.TableOptimizerHint {
+	switch x := stmtNode.(type) {
+	case *ast.SelectStmt:
+		return x.TableHints
+	case *ast.UpdateStmt:
+		return x.TableHints
+	case *ast.DeleteStmt:
+		return x.TableHints
+	}
+	return nil
+}
+
+// hasAllowExpensiveHint reports whether stmtNode carries a
+// `/*+ ALLOW_EXPENSIVE() */` hint.
+func hasAllowExpensiveHint(stmtNode ast.StmtNode) bool {
+	for _, hint := range tableHints(stmtNode) {
+		if hint.HintName.L == allowExpensiveHintName {
+			return true
+		}
+	}
+	return false
+}
+
+// allowExpensiveQueryOverride reports whether a tooExpensive classification
+// should be downgraded back to expensive for this statement, either because
+// the session has tidbAllowExpensiveQuery (see sysvar.go) set to "1", or
+// because the statement itself carries an ALLOW_EXPENSIVE hint. The sysvar
+// is read directly off SessionVars rather than through a dedicated bool
+// field: a bool field only reflects SET if something dispatches SET to it,
+// and this series adds no such dispatch, so the field would just silently
+// never be true.
+func allowExpensiveQueryOverride(ctx sessionctx.Context, stmtNode ast.StmtNode) bool {
+	if val, ok := ctx.GetSessionVars().GetSystemVar(tidbAllowExpensiveQuery); ok && val == "1" {
+		return true
+	}
+	return hasAllowExpensiveHint(stmtNode)
+}
@@ -0,0 +1,22 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import "testing"
+
+func TestHasAllowExpensiveHintNilStmt(t *testing.T) {
+	if hasAllowExpensiveHint(nil) {
+		t.Errorf("hasAllowExpensiveHint(nil) = true, want false (statement types with no TableHints carry no hints)")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/tidb/config"
+	"github.com/pingcap/tidb/sessionctx"
+)
+
+// ResourceGroup describes the resource limits that apply to the queries
+// issued by a user or role. Groups are loaded from the `[resource-groups]`
+// section of the config file or registered with RegisterResourceGroup, and
+// are resolved once per statement in Compiler.Compile.
+type ResourceGroup struct {
+	Name string
+
+	// MaxEstimatedRows rejects/cancels a query whose optimizer-estimated (or,
+	// at runtime for a statement classified as expensive, actually
+	// processed) row count exceeds this bound. Zero means unlimited.
+	MaxEstimatedRows int64
+	// MaxEstimatedMemory bounds the estimated or (for a statement classified
+	// as expensive) actually-tracked memory usage of a single statement, in
+	// bytes. Zero means unlimited.
+	MaxEstimatedMemory int64
+	// MaxConcurrentExpensive bounds how many queries classified as
+	// "expensive" this group may run at the same time. Zero means
+	// unlimited.
+	MaxConcurrentExpensive int
+	// MaxWallTime bounds how long a single statement classified as
+	// expensive may run before it is cancelled; statements that are not
+	// expensive are not timed. Zero means unlimited.
+	MaxWallTime time.Duration
+}
+
+// defaultResourceGroup is used for sessions that are not assigned to any
+// named group. It carries no limits, preserving today's behavior.
+var defaultResourceGroup = &ResourceGroup{Name: "default"}
+
+var resourceGroupManager = struct {
+	sync.RWMutex
+	// groups maps group name to its definition.
+	groups map[string]*ResourceGroup
+	// userGroups maps a user name to the group it is assigned to.
+	userGroups map[string]string
+	// running tracks how many expensive queries are currently executing
+	// per group, to enforce MaxConcurrentExpensive.
+	running map[string]int
+}{
+	groups:     make(map[string]*ResourceGroup),
+	userGroups: make(map[string]string),
+	running:    make(map[string]int),
+}
+
+// RegisterResourceGroup installs or replaces a resource group definition.
+// It is called by config loading (loadResourceGroupsFromConfig).
+func RegisterResourceGroup(group *ResourceGroup) {
+	resourceGroupManager.Lock()
+	defer resourceGroupManager.Unlock()
+	resourceGroupManager.groups[group.Name] = group
+}
+
+// AssignUserResourceGroup assigns a user to a previously registered
+// resource group.
+func AssignUserResourceGroup(user, group string) {
+	resourceGroupManager.Lock()
+	defer resourceGroupManager.Unlock()
+	resourceGroupManager.userGroups[user] = group
+}
+
+// loadResourceGroupsOnce guards the one-time load of
+// config.GetGlobalConfig().ResourceGroups.Groups from the
+// `[resource-groups]` config section, so that statically configured groups
+// are reachable without a separate registration step.
+var loadResourceGroupsOnce sync.Once
+
+// loadResourceGroupsFromConfig registers every group listed under
+// `[resource-groups]` in the config file, and assigns the users listed
+// against each one. It is safe to call repeatedly; only the first call
+// has any effect.
+func loadResourceGroupsFromConfig() {
+	loadResourceGroupsOnce.Do(func() {
+		for _, rg := range config.GetGlobalConfig().ResourceGroups.Groups {
+			group := &ResourceGroup{
+				Name:                   rg.Name,
+				MaxEstimatedRows:       rg.MaxEstimatedRows,
+				MaxEstimatedMemory:     rg.MaxEstimatedMemory,
+				MaxConcurrentExpensive: rg.MaxConcurrentExpensive,
+				MaxWallTime:            time.Duration(rg.MaxWallTimeSeconds) * time.Second,
+			}
+			RegisterResourceGroup(group)
+			for _, user := range rg.Users {
+				AssignUserResourceGroup(user, group.Name)
+			}
+		}
+	})
+}
+
+// resolveResourceGroup returns the resource group that applies to the
+// session, falling back to defaultResourceGroup when the user has not been
+// assigned one.
+func resolveResourceGroup(ctx sessionctx.Context) *ResourceGroup {
+	loadResourceGroupsFromConfig()
+
+	sessVars := ctx.GetSessionVars()
+	if sessVars == nil || sessVars.User == nil {
+		return defaultResourceGroup
+	}
+	return resolveResourceGroupForUser(sessVars.User.Username)
+}
+
+// resolveResourceGroupForUser looks up the group assigned to username,
+// falling back to defaultResourceGroup when the user has not been assigned
+// one, or was assigned a group name that is no longer registered. Split out
+// of resolveResourceGroup so the fallback logic can be tested directly,
+// without needing a sessionctx.Context.
+func resolveResourceGroupForUser(username string) *ResourceGroup {
+	resourceGroupManager.RLock()
+	defer resourceGroupManager.RUnlock()
+	groupName, ok := resourceGroupManager.userGroups[username]
+	if !ok {
+		return defaultResourceGroup
+	}
+	if group, ok := resourceGroupManager.groups[groupName]; ok {
+		return group
+	}
+	return defaultResourceGroup
+}
+
+// tryEnterExpensive reserves a concurrent-expensive-query slot for the
+// group, returning false if the group is already at its limit.
+func tryEnterExpensive(group *ResourceGroup) bool {
+	if group.MaxConcurrentExpensive == 0 {
+		return true
+	}
+	resourceGroupManager.Lock()
+	defer resourceGroupManager.Unlock()
+	if resourceGroupManager.running[group.Name] >= group.MaxConcurrentExpensive {
+		return false
+	}
+	resourceGroupManager.running[group.Name]++
+	return true
+}
+
+// leaveExpensive releases a slot reserved by tryEnterExpensive.
+func leaveExpensive(group *ResourceGroup) {
+	if group.MaxConcurrentExpensive == 0 {
+		return
+	}
+	resourceGroupManager.Lock()
+	defer resourceGroupManager.Unlock()
+	if resourceGroupManager.running[group.Name] > 0 {
+		resourceGroupManager.running[group.Name]--
+	}
+}
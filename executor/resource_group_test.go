@@ -0,0 +1,124 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import "testing"
+
+// resetResourceGroupManager clears any groups/assignments/running counts
+// registered by a test, so later tests aren't affected by leftovers.
+func resetResourceGroupManager() {
+	resourceGroupManager.Lock()
+	defer resourceGroupManager.Unlock()
+	resourceGroupManager.groups = make(map[string]*ResourceGroup)
+	resourceGroupManager.userGroups = make(map[string]string)
+	resourceGroupManager.running = make(map[string]int)
+}
+
+func TestTryEnterExpensiveRespectsLimit(t *testing.T) {
+	resetResourceGroupManager()
+	defer resetResourceGroupManager()
+
+	group := &ResourceGroup{Name: "limited", MaxConcurrentExpensive: 2}
+	if !tryEnterExpensive(group) {
+		t.Fatalf("tryEnterExpensive() = false, want true (1st of 2)")
+	}
+	if !tryEnterExpensive(group) {
+		t.Fatalf("tryEnterExpensive() = false, want true (2nd of 2)")
+	}
+	if tryEnterExpensive(group) {
+		t.Fatalf("tryEnterExpensive() = true, want false (group already at limit)")
+	}
+}
+
+func TestTryEnterExpensiveUnlimitedWhenZero(t *testing.T) {
+	resetResourceGroupManager()
+	defer resetResourceGroupManager()
+
+	group := &ResourceGroup{Name: "unlimited"}
+	for i := 0; i < 100; i++ {
+		if !tryEnterExpensive(group) {
+			t.Fatalf("tryEnterExpensive() = false on call %d, want true (MaxConcurrentExpensive == 0 means unlimited)", i)
+		}
+	}
+}
+
+func TestLeaveExpensiveFreesSlot(t *testing.T) {
+	resetResourceGroupManager()
+	defer resetResourceGroupManager()
+
+	group := &ResourceGroup{Name: "limited", MaxConcurrentExpensive: 1}
+	if !tryEnterExpensive(group) {
+		t.Fatalf("tryEnterExpensive() = false, want true")
+	}
+	if tryEnterExpensive(group) {
+		t.Fatalf("tryEnterExpensive() = true, want false (group already at limit)")
+	}
+
+	leaveExpensive(group)
+	if !tryEnterExpensive(group) {
+		t.Fatalf("tryEnterExpensive() = false after leaveExpensive, want true (slot should be freed)")
+	}
+}
+
+func TestLeaveExpensiveNeverGoesNegative(t *testing.T) {
+	resetResourceGroupManager()
+	defer resetResourceGroupManager()
+
+	group := &ResourceGroup{Name: "limited", MaxConcurrentExpensive: 1}
+	leaveExpensive(group)
+	leaveExpensive(group)
+
+	resourceGroupManager.RLock()
+	running := resourceGroupManager.running[group.Name]
+	resourceGroupManager.RUnlock()
+	if running != 0 {
+		t.Errorf("running count = %d after leaveExpensive on empty group, want 0 (must not go negative)", running)
+	}
+}
+
+func TestResolveResourceGroupForUserFallsBackToDefault(t *testing.T) {
+	resetResourceGroupManager()
+	defer resetResourceGroupManager()
+
+	got := resolveResourceGroupForUser("nobody")
+	if got != defaultResourceGroup {
+		t.Errorf("resolveResourceGroupForUser(unassigned user) = %+v, want defaultResourceGroup", got)
+	}
+}
+
+func TestResolveResourceGroupForUserReturnsAssignedGroup(t *testing.T) {
+	resetResourceGroupManager()
+	defer resetResourceGroupManager()
+
+	group := &ResourceGroup{Name: "batch", MaxEstimatedRows: 1000}
+	RegisterResourceGroup(group)
+	AssignUserResourceGroup("batch_user", "batch")
+
+	got := resolveResourceGroupForUser("batch_user")
+	if got != group {
+		t.Errorf("resolveResourceGroupForUser(batch_user) = %+v, want %+v", got, group)
+	}
+}
+
+func TestResolveResourceGroupForUserFallsBackWhenGroupMissing(t *testing.T) {
+	resetResourceGroupManager()
+	defer resetResourceGroupManager()
+
+	AssignUserResourceGroup("orphan_user", "does_not_exist")
+
+	got := resolveResourceGroupForUser("orphan_user")
+	if got != defaultResourceGroup {
+		t.Errorf("resolveResourceGroupForUser(user assigned to deleted group) = %+v, want defaultResourceGroup", got)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"sync"
+
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/plan"
+)
+
+// StmtLabeler produces a metric/audit label for a compiled statement. It
+// sees both the raw AST and the optimized plan, so it can distinguish
+// access patterns (e.g. point-get vs full scan) that the AST alone cannot
+// express. A labeler should return "" to defer to the next registered
+// labeler, or to the default one.
+type StmtLabeler func(stmtNode ast.StmtNode, finalPlan plan.Plan) string
+
+var stmtLabelers struct {
+	sync.RWMutex
+	fns []StmtLabeler
+}
+
+// RegisterStmtLabeler installs an additional statement labeler. Labelers
+// are tried most-recently-registered first; the built-in default labeler
+// runs last. This lets subsystems such as metrics, audit, or resource
+// control install richer labels without editing GetStmtLabel itself.
+func RegisterStmtLabeler(fn StmtLabeler) {
+	stmtLabelers.Lock()
+	defer stmtLabelers.Unlock()
+	stmtLabelers.fns = append(stmtLabelers.fns, fn)
+}
+
+// GetStmtLabel generates a label for a statement, consulting any labelers
+// registered with RegisterStmtLabeler before falling back to the default
+// labeler below.
+func GetStmtLabel(stmtNode ast.StmtNode, finalPlan plan.Plan) string {
+	stmtLabelers.RLock()
+	fns := stmtLabelers.fns
+	stmtLabelers.RUnlock()
+
+	for i := len(fns) - 1; i >= 0; i-- {
+		if label := fns[i](stmtNode, finalPlan); label != "" {
+			return label
+		}
+	}
+	return defaultStmtLabel(stmtNode, finalPlan)
+}
+
+// defaultStmtLabel is the built-in labeler, producing a multi-dimensional
+// label such as "Select/PointGet" or "Insert/Batch" where the plan gives
+// enough information to refine the AST-level label.
+func defaultStmtLabel(stmtNode ast.StmtNode, finalPlan plan.Plan) string {
+	switch x := stmtNode.(type) {
+	case *ast.AlterTableStmt:
+		return "AlterTable"
+	case *ast.AnalyzeTableStmt:
+		return "AnalyzeTable"
+	case *ast.BeginStmt:
+		return "Begin"
+	case *ast.CommitStmt:
+		return "Commit"
+	case *ast.CreateDatabaseStmt:
+		return "CreateDatabase"
+	case *ast.CreateIndexStmt:
+		return "CreateIndex"
+	case *ast.CreateTableStmt:
+		return "CreateTable"
+	case *ast.CreateUserStmt:
+		return "CreateUser"
+	case *ast.DeleteStmt:
+		return "Delete"
+	case *ast.DropDatabaseStmt:
+		return "DropDatabase"
+	case *ast.DropIndexStmt:
+		return "DropIndex"
+	case *ast.DropTableStmt:
+		return "DropTable"
+	case *ast.ExplainStmt:
+		return "Explain"
+	case *ast.InsertStmt:
+		if x.IsReplace {
+			return "Replace"
+		}
+		if len(x.Lists) > 1 {
+			return "Insert/Batch"
+		}
+		return "Insert"
+	case *ast.LoadDataStmt:
+		return "LoadData"
+	case *ast.RollbackStmt:
+		return "RollBack"
+	case *ast.SelectStmt:
+		return "Select/" + selectAccessPattern(finalPlan)
+	case *ast.SetStmt, *ast.SetPwdStmt:
+		return "Set"
+	case *ast.ShowStmt:
+		return "Show"
+	case *ast.TruncateTableStmt:
+		return "TruncateTable"
+	case *ast.UpdateStmt:
+		return "Update"
+	case *ast.GrantStmt:
+		return "Grant"
+	case *ast.RevokeStmt:
+		return "Revoke"
+	case *ast.DeallocateStmt:
+		return "Deallocate"
+	case *ast.ExecuteStmt:
+		return "Execute"
+	case *ast.PrepareStmt:
+		return "Prepare"
+	case *ast.UseStmt:
+		return "IGNORE"
+	}
+	return "other"
+}
+
+// selectAccessPattern classifies a SELECT's optimized plan by how it reads
+// its data, e.g. "PointGet", "IndexScan", "TableScan". The first
+// recognizable access path found depth-first wins, so a join is labeled by
+// the access path of its first such child. It falls back to "Other" when no
+// operator in the plan is a recognizable index or table read.
+func selectAccessPattern(finalPlan plan.Plan) string {
+	if _, ok := finalPlan.(*plan.PointGetPlan); ok {
+		return "PointGet"
+	}
+	physical, ok := finalPlan.(plan.PhysicalPlan)
+	if !ok {
+		return "Other"
+	}
+	return physicalAccessPattern(physical)
+}
+
+func physicalAccessPattern(p plan.PhysicalPlan) string {
+	switch p.(type) {
+	case *plan.PhysicalIndexScan, *plan.PhysicalIndexReader, *plan.PhysicalIndexLookUpReader:
+		return "IndexScan"
+	case *plan.PhysicalTableScan, *plan.PhysicalTableReader:
+		return "TableScan"
+	}
+	for _, child := range p.Children() {
+		if pattern := physicalAccessPattern(child); pattern != "Other" {
+			return pattern
+		}
+	}
+	return "Other"
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/ast"
+	"github.com/pingcap/tidb/plan"
+)
+
+// resetStmtLabelers clears any labelers registered by a test, so later
+// tests (or the default labeler) aren't affected by leftovers.
+func resetStmtLabelers() {
+	stmtLabelers.Lock()
+	defer stmtLabelers.Unlock()
+	stmtLabelers.fns = nil
+}
+
+func TestGetStmtLabelDefersToDefaultWhenNoLabelerMatches(t *testing.T) {
+	resetStmtLabelers()
+	defer resetStmtLabelers()
+
+	RegisterStmtLabeler(func(_ ast.StmtNode, _ plan.Plan) string {
+		return ""
+	})
+
+	if got := GetStmtLabel(nil, nil); got != "other" {
+		t.Errorf("GetStmtLabel(nil, nil) = %q, want %q (fallback to default labeler)", got, "other")
+	}
+}
+
+func TestGetStmtLabelPrefersMostRecentlyRegistered(t *testing.T) {
+	resetStmtLabelers()
+	defer resetStmtLabelers()
+
+	RegisterStmtLabeler(func(_ ast.StmtNode, _ plan.Plan) string { return "first" })
+	RegisterStmtLabeler(func(_ ast.StmtNode, _ plan.Plan) string { return "second" })
+
+	if got := GetStmtLabel(nil, nil); got != "second" {
+		t.Errorf("GetStmtLabel(nil, nil) = %q, want %q (most recently registered wins)", got, "second")
+	}
+}
+
+func TestGetStmtLabelSkipsLabelerThatDefers(t *testing.T) {
+	resetStmtLabelers()
+	defer resetStmtLabelers()
+
+	RegisterStmtLabeler(func(_ ast.StmtNode, _ plan.Plan) string { return "fallback-candidate" })
+	RegisterStmtLabeler(func(_ ast.StmtNode, _ plan.Plan) string { return "" })
+
+	if got := GetStmtLabel(nil, nil); got != "fallback-candidate" {
+		t.Errorf("GetStmtLabel(nil, nil) = %q, want %q (labeler returning \"\" is skipped)", got, "fallback-candidate")
+	}
+}
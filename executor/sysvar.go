@@ -0,0 +1,30 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package executor
+
+import "github.com/pingcap/tidb/sessionctx/variable"
+
+// tidbAllowExpensiveQuery is the session variable name checked by
+// allowExpensiveQueryOverride through SessionVars.GetSystemVar. Registering
+// it here is what makes `SET tidb_allow_expensive_query = 1` resolve to
+// something.
+const tidbAllowExpensiveQuery = "tidb_allow_expensive_query"
+
+func init() {
+	variable.SysVars[tidbAllowExpensiveQuery] = &variable.SysVar{
+		Scope: variable.ScopeSession,
+		Name:  tidbAllowExpensiveQuery,
+		Value: "0",
+	}
+}
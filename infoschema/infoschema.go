@@ -0,0 +1,23 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infoschema
+
+// InfoSchema is a read-only snapshot of schema information as of a given
+// schema version. Only the parts of the real interface that executor
+// actually depends on are modeled here.
+type InfoSchema interface {
+	// SchemaMetaVersion returns the schema version this snapshot was built
+	// from.
+	SchemaMetaVersion() int64
+}
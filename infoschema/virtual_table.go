@@ -0,0 +1,57 @@
+// Copyright 2015 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infoschema
+
+import (
+	"sync"
+
+	"github.com/pingcap/tidb/types"
+)
+
+// VirtualTable describes an INFORMATION_SCHEMA table whose rows are
+// computed on read rather than stored, e.g. EXPENSIVE_QUERIES.
+type VirtualTable struct {
+	// Cols is the column name list, in display order.
+	Cols []string
+	// Rows builds the current row data, in the order described by Cols.
+	Rows func() [][]types.Datum
+}
+
+var virtualTables struct {
+	sync.RWMutex
+	tables map[string]VirtualTable
+}
+
+func init() {
+	virtualTables.tables = make(map[string]VirtualTable)
+}
+
+// RegisterVirtualTable installs a virtual INFORMATION_SCHEMA table under
+// name. Packages that compute their own virtual table data (such as
+// executor's EXPENSIVE_QUERIES) register here instead of infoschema
+// depending on them directly, which would be circular: infoschema is
+// imported by executor for the InfoSchema interface (infoschema.go).
+func RegisterVirtualTable(name string, table VirtualTable) {
+	virtualTables.Lock()
+	defer virtualTables.Unlock()
+	virtualTables.tables[name] = table
+}
+
+// GetVirtualTable returns the virtual table registered under name, if any.
+func GetVirtualTable(name string) (VirtualTable, bool) {
+	virtualTables.RLock()
+	defer virtualTables.RUnlock()
+	table, ok := virtualTables.tables[name]
+	return table, ok
+}